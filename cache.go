@@ -0,0 +1,150 @@
+// Copyright 2013-2018 C Hansen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"container/list"
+	"io"
+	"sync"
+)
+
+// CacheOptions configures the in-memory decompression cache installed by
+// ZipFS.WithCache.
+type CacheOptions struct {
+	// MaxEntryBytes is the largest uncompressed entry size eligible to be
+	// held fully in memory; entries above this always spill to a shared
+	// temp file instead. Zero disables the in-memory cache entirely.
+	MaxEntryBytes int64
+	// MaxTotalBytes bounds the combined size of every entry currently held
+	// in memory. Once exceeded, entries are evicted least-recently-used
+	// first. Zero means unbounded.
+	MaxTotalBytes int64
+}
+
+// memCache is a bounded LRU of fully-decompressed entry contents, keyed by
+// fileInfo so that repeated opens of the same entry are served from memory.
+type memCache struct {
+	mu         sync.Mutex
+	opts       CacheOptions
+	data       map[*fileInfo][]byte
+	elems      map[*fileInfo]*list.Element
+	order      *list.List // front = most recently used
+	totalBytes int64
+}
+
+func newMemCache(opts CacheOptions) *memCache {
+	return &memCache{
+		opts:  opts,
+		data:  map[*fileInfo][]byte{},
+		elems: map[*fileInfo]*list.Element{},
+		order: list.New(),
+	}
+}
+
+// open returns a seeker over fi's decompressed contents if fi is eligible
+// for the in-memory cache (fi.Size() <= opts.MaxEntryBytes), decompressing
+// and storing it on first use. ok is false when fi is not eligible, in
+// which case the caller should fall back to fi.openSpill.
+func (c *memCache) open(fi *fileInfo) (seeker io.ReadSeekCloser, ok bool, err error) {
+	if c.opts.MaxEntryBytes <= 0 || fi.Size() > c.opts.MaxEntryBytes {
+		return nil, false, nil
+	}
+
+	c.mu.Lock()
+	if data, found := c.data[fi]; found {
+		c.touch(fi)
+		c.mu.Unlock()
+		return &memReader{Reader: bytes.NewReader(data)}, true, nil
+	}
+	c.mu.Unlock()
+
+	data, err := decompress(fi.zipFile)
+	if err != nil {
+		return nil, true, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if existing, found := c.data[fi]; found {
+		// Lost a race with another goroutine decompressing the same entry;
+		// keep whichever copy is already cached.
+		c.touch(fi)
+		return &memReader{Reader: bytes.NewReader(existing)}, true, nil
+	}
+	c.data[fi] = data
+	c.totalBytes += int64(len(data))
+	c.elems[fi] = c.order.PushFront(fi)
+	c.evict()
+	return &memReader{Reader: bytes.NewReader(data)}, true, nil
+}
+
+func (c *memCache) touch(fi *fileInfo) {
+	if elem, ok := c.elems[fi]; ok {
+		c.order.MoveToFront(elem)
+	}
+}
+
+// evict drops least-recently-used entries until totalBytes is within
+// opts.MaxTotalBytes. Callers must hold c.mu.
+func (c *memCache) evict() {
+	for c.opts.MaxTotalBytes > 0 && c.totalBytes > c.opts.MaxTotalBytes && c.order.Len() > 0 {
+		back := c.order.Back()
+		victim := back.Value.(*fileInfo)
+		c.totalBytes -= int64(len(c.data[victim]))
+		delete(c.data, victim)
+		delete(c.elems, victim)
+		c.order.Remove(back)
+	}
+}
+
+// close discards every cached entry's decompressed bytes.
+func (c *memCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data = map[*fileInfo][]byte{}
+	c.elems = map[*fileInfo]*list.Element{}
+	c.order = list.New()
+	c.totalBytes = 0
+}
+
+// decompress reads the entirety of zf's decompressed contents into memory,
+// reusing bufPool's buffers for the copy.
+func decompress(zf *zip.File) ([]byte, error) {
+	r, err := zf.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var buf bytes.Buffer
+	b := bufPool.Get()
+	defer bufPool.Free(b)
+	if _, err := io.CopyBuffer(&buf, r, b[:]); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// memReader adapts a bytes.Reader, which has no Close method, to
+// io.ReadSeekCloser for use as a fileReader's seek.
+type memReader struct {
+	*bytes.Reader
+}
+
+func (m *memReader) Close() error {
+	return nil
+}
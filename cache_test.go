@@ -0,0 +1,139 @@
+// Copyright 2013-2018 C Hansen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+func buildCacheFixtureZip(t *testing.T, names []string, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Create(%q): %v", name, err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("Write(%q): %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestMemCacheEvictsLRU verifies that once the cache's combined size exceeds
+// MaxTotalBytes, open evicts the least-recently-used entry. Each entry is
+// opened once, so that entry is also the one cached first.
+func TestMemCacheEvictsLRU(t *testing.T) {
+	const content = "0123456789" // 10 bytes, well under MaxEntryBytes
+	names := []string{"a.txt", "b.txt", "c.txt"}
+	data := buildCacheFixtureZip(t, names, content)
+
+	z, err := NewFromReaderAt(bytes.NewReader(data), int64(len(data)), nil)
+	if err != nil {
+		t.Fatalf("NewFromReaderAt: %v", err)
+	}
+	defer z.Close()
+	z.WithCache(CacheOptions{MaxEntryBytes: 100, MaxTotalBytes: 25})
+
+	open := func(name string) {
+		t.Helper()
+		fi := z.fileInfos[name]
+		if fi == nil {
+			t.Fatalf("no fileInfo for %q", name)
+		}
+		seeker, ok, err := z.cache.open(fi)
+		if err != nil {
+			t.Fatalf("cache.open(%q): %v", name, err)
+		}
+		if !ok {
+			t.Fatalf("cache.open(%q): not eligible for caching", name)
+		}
+		got, err := io.ReadAll(seeker)
+		if err != nil {
+			t.Fatalf("read %q: %v", name, err)
+		}
+		if string(got) != content {
+			t.Fatalf("read %q = %q, want %q", name, got, content)
+		}
+		seeker.Close()
+	}
+
+	// MaxTotalBytes: 25 only fits two 10-byte entries, so opening all three
+	// in order should evict "a.txt", the least recently used at that point.
+	open("a.txt")
+	open("b.txt")
+	open("c.txt")
+
+	z.cache.mu.Lock()
+	_, aCached := z.cache.data[z.fileInfos["a.txt"]]
+	_, bCached := z.cache.data[z.fileInfos["b.txt"]]
+	_, cCached := z.cache.data[z.fileInfos["c.txt"]]
+	z.cache.mu.Unlock()
+
+	if aCached {
+		t.Error("a.txt should have been evicted as least-recently-used")
+	}
+	if !bCached || !cCached {
+		t.Error("b.txt and c.txt should still be cached")
+	}
+}
+
+// TestFileInfoSpillRefCounting verifies that openSpill shares a single temp
+// file across concurrent callers and only removes it once every caller has
+// released its reference via closeSpill.
+func TestFileInfoSpillRefCounting(t *testing.T) {
+	data := buildCacheFixtureZip(t, []string{"a.txt"}, "contents")
+
+	z, err := NewFromReaderAt(bytes.NewReader(data), int64(len(data)), nil)
+	if err != nil {
+		t.Fatalf("NewFromReaderAt: %v", err)
+	}
+	defer z.Close()
+
+	fi := z.fileInfos["a.txt"]
+	f1, err := fi.openSpill()
+	if err != nil {
+		t.Fatalf("openSpill #1: %v", err)
+	}
+	f2, err := fi.openSpill()
+	if err != nil {
+		t.Fatalf("openSpill #2: %v", err)
+	}
+	if f1.Name() != f2.Name() {
+		t.Fatalf("openSpill returned different temp files: %q != %q", f1.Name(), f2.Name())
+	}
+	tempPath := f1.Name()
+
+	f1.Close()
+	fi.closeSpill()
+	if _, err := os.Stat(tempPath); err != nil {
+		t.Fatalf("temp file removed while a reference is still outstanding: %v", err)
+	}
+
+	f2.Close()
+	fi.closeSpill()
+	if _, err := os.Stat(tempPath); !os.IsNotExist(err) {
+		t.Fatalf("temp file still exists after the last reference was released: err=%v", err)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2013-2018 C Hansen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package codecs registers decompressors, with the global archive/zip
+// registry, for zip methods that archive/zip does not implement natively:
+// zstd (method 93) via klauspost/compress and bzip2 (method 12) via
+// dsnet/compress. Importing the package for its side effect is enough; it
+// does not export anything itself.
+//
+//	import _ "chan58/zipfs/codecs"
+package codecs
+
+import (
+	"io"
+
+	"github.com/dsnet/compress/bzip2"
+	"github.com/klauspost/compress/zstd"
+
+	"chan58/zipfs"
+)
+
+func init() {
+	zipfs.RegisterDecompressor(zipfs.ZSTD, newZstdDecompressor)
+	zipfs.RegisterDecompressor(zipfs.BZIP2, newBzip2Decompressor)
+}
+
+func newZstdDecompressor(r io.Reader) io.ReadCloser {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return errReadCloser{err}
+	}
+	return zr.IOReadCloser()
+}
+
+func newBzip2Decompressor(r io.Reader) io.ReadCloser {
+	br, err := bzip2.NewReader(r, nil)
+	if err != nil {
+		return errReadCloser{err}
+	}
+	return br
+}
+
+// errReadCloser turns a decompressor construction error into an
+// io.ReadCloser that surfaces the error on the first Read, since
+// zip.Decompressor has no way to report an error except through the reader
+// it returns.
+type errReadCloser struct {
+	err error
+}
+
+func (e errReadCloser) Read([]byte) (int, error) { return 0, e.err }
+func (e errReadCloser) Close() error             { return nil }
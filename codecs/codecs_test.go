@@ -0,0 +1,117 @@
+// Copyright 2013-2018 C Hansen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codecs_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"hash/crc32"
+	"testing"
+
+	"github.com/dsnet/compress/bzip2"
+	"github.com/klauspost/compress/zstd"
+
+	"chan58/zipfs"
+	_ "chan58/zipfs/codecs"
+)
+
+const fixtureContent = "the quick brown fox jumps over the lazy dog\n"
+
+// rawEntry compresses content with compress and appends a zip.FileHeader
+// entry carrying method and the already-compressed bytes directly, via
+// CreateRaw, so the fixture doesn't depend on archive/zip having a
+// registered Compressor for method.
+func rawEntry(t *testing.T, zw *zip.Writer, name string, method uint16, compress func([]byte) []byte) {
+	t.Helper()
+	content := []byte(fixtureContent)
+	compressed := compress(content)
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               name,
+		Method:             method,
+		CRC32:              crc32.ChecksumIEEE(content),
+		UncompressedSize64: uint64(len(content)),
+		CompressedSize64:   uint64(len(compressed)),
+	})
+	if err != nil {
+		t.Fatalf("CreateRaw(%q): %v", name, err)
+	}
+	if _, err := w.Write(compressed); err != nil {
+		t.Fatalf("write raw %q: %v", name, err)
+	}
+}
+
+func buildFixtureZip(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	rawEntry(t, zw, "zstd.txt", zipfs.ZSTD, func(content []byte) []byte {
+		var out bytes.Buffer
+		enc, err := zstd.NewWriter(&out)
+		if err != nil {
+			t.Fatalf("zstd.NewWriter: %v", err)
+		}
+		if _, err := enc.Write(content); err != nil {
+			t.Fatalf("zstd write: %v", err)
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatalf("zstd close: %v", err)
+		}
+		return out.Bytes()
+	})
+
+	rawEntry(t, zw, "bzip2.txt", zipfs.BZIP2, func(content []byte) []byte {
+		var out bytes.Buffer
+		enc, err := bzip2.NewWriter(&out, nil)
+		if err != nil {
+			t.Fatalf("bzip2.NewWriter: %v", err)
+		}
+		if _, err := enc.Write(content); err != nil {
+			t.Fatalf("bzip2 write: %v", err)
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatalf("bzip2 close: %v", err)
+		}
+		return out.Bytes()
+	})
+
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestDecompress verifies that importing codecs for its side effect is
+// enough for ZipFS to transparently read zstd- and bzip2-compressed
+// entries, without the caller ever touching the registry directly.
+func TestDecompress(t *testing.T) {
+	data := buildFixtureZip(t)
+	z, err := zipfs.NewFromReaderAt(bytes.NewReader(data), int64(len(data)), nil)
+	if err != nil {
+		t.Fatalf("NewFromReaderAt: %v", err)
+	}
+	defer z.Close()
+
+	for _, name := range []string{"zstd.txt", "bzip2.txt"} {
+		got, err := z.ReadFile(name)
+		if err != nil {
+			t.Errorf("ReadFile(%q): %v", name, err)
+			continue
+		}
+		if string(got) != fixtureContent {
+			t.Errorf("ReadFile(%q) = %q, want %q", name, got, fixtureContent)
+		}
+	}
+}
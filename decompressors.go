@@ -0,0 +1,47 @@
+// Copyright 2013-2018 C Hansen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipfs
+
+import "archive/zip"
+
+// Compression method IDs for formats archive/zip doesn't implement natively
+// (it only ships Store and Deflate decompressors). These match the values
+// assigned in the ZIP APPNOTE and the tools that use them in practice.
+// zipfs/codecs registers decompressors for ZSTD and BZIP2 only; nothing in
+// this module registers one for LZMA or XZ.
+const (
+	BZIP2 uint16 = 12
+	LZMA  uint16 = 14
+	ZSTD  uint16 = 93
+	XZ    uint16 = 95
+)
+
+// RegisterDecompressor registers dcomp globally for method, exactly as
+// archive/zip.RegisterDecompressor. Codec packages such as zipfs/codecs call
+// this from an init function, so importing them purely for their side
+// effect is enough to make every ZipFS opened afterwards understand the
+// method.
+func RegisterDecompressor(method uint16, dcomp zip.Decompressor) {
+	zip.RegisterDecompressor(method, dcomp)
+}
+
+// RegisterDecompressor registers dcomp for method on z only, overriding
+// both the global registry and any earlier override on z. It forwards to
+// the underlying *zip.Reader's own RegisterDecompressor, so it takes effect
+// for every entry subsequently opened through z without touching any other
+// ZipFS.
+func (z *ZipFS) RegisterDecompressor(method uint16, dcomp zip.Decompressor) {
+	z.reader.RegisterDecompressor(method, dcomp)
+}
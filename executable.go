@@ -0,0 +1,120 @@
+// Copyright 2013-2018 C Hansen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipfs
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrNoZipPayload is returned by NewFromExecutable when no zip archive
+// could be located, either appended to the file or embedded in one of its
+// sections.
+var ErrNoZipPayload = errors.New("zipfs: no zip payload found in executable")
+
+// NewFromExecutable opens the ELF, Mach-O, or PE executable at path and
+// mounts a zip filesystem from a zip payload carried by it, the pattern
+// used by self-extracting Go binaries and godoc-style single-file
+// distributions.
+//
+// It first tries the whole file as a zip archive: archive/zip already
+// tolerates leading junk when locating the end-of-central-directory record,
+// so a zip simply concatenated onto the end of the executable is found
+// directly. If that fails, NewFromExecutable parses the executable's object
+// format and retries against each of its sections in turn, for payloads
+// embedded inside a section rather than appended to the file.
+func NewFromExecutable(path string, opts ...Option) (*ZipFS, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	size := fi.Size()
+
+	if zfs, err := NewFromReaderAt(f, size, nil, opts...); err == nil {
+		zfs.closer = f
+		return zfs, nil
+	}
+
+	for _, sec := range executableSections(f, size) {
+		sr := io.NewSectionReader(f, sec.offset, sec.size)
+		if zfs, err := NewFromReaderAt(sr, sec.size, nil, opts...); err == nil {
+			zfs.closer = f
+			return zfs, nil
+		}
+	}
+
+	f.Close()
+	return nil, ErrNoZipPayload
+}
+
+type executableSection struct {
+	offset int64
+	size   int64
+}
+
+// executableSections returns the offset and size, within f, of every
+// section of f's ELF, Mach-O, or PE object format. It is a best-effort
+// fallback for NewFromExecutable: an unrecognized format or a read failure
+// simply yields no sections, same as a recognized format with no payload.
+func executableSections(f *os.File, size int64) []executableSection {
+	ra := io.NewSectionReader(f, 0, size)
+
+	if ef, err := elf.NewFile(ra); err == nil {
+		defer ef.Close()
+		var secs []executableSection
+		for _, s := range ef.Sections {
+			if s.Type == elf.SHT_NOBITS || s.Size == 0 {
+				continue
+			}
+			secs = append(secs, executableSection{offset: int64(s.Offset), size: int64(s.Size)})
+		}
+		return secs
+	}
+
+	if mf, err := macho.NewFile(ra); err == nil {
+		defer mf.Close()
+		var secs []executableSection
+		for _, s := range mf.Sections {
+			if s.Size == 0 {
+				continue
+			}
+			secs = append(secs, executableSection{offset: int64(s.Offset), size: int64(s.Size)})
+		}
+		return secs
+	}
+
+	if pf, err := pe.NewFile(ra); err == nil {
+		defer pf.Close()
+		var secs []executableSection
+		for _, s := range pf.Sections {
+			if s.Size == 0 {
+				continue
+			}
+			secs = append(secs, executableSection{offset: int64(s.Offset), size: int64(s.Size)})
+		}
+		return secs
+	}
+
+	return nil
+}
@@ -30,10 +30,13 @@ import (
 
 // ZipFS implements the http.FileSystem interface.
 type ZipFS struct {
-	readerAt  io.ReaderAt
-	closer    io.Closer
-	reader    *zip.Reader
-	fileInfos fileInfoMap
+	readerAt    io.ReaderAt
+	closer      io.Closer
+	reader      *zip.Reader
+	fileInfos   fileInfoMap
+	cache       *memCache
+	opts        options
+	foldedIndex map[string]*fileInfo // non-nil if CaseInsensitive or Normalization was requested
 }
 
 var (
@@ -44,19 +47,52 @@ var (
 )
 
 // New instantiates and returns a Zip filesystem
-func New(name string) (*ZipFS, error) {
+func New(name string, opts ...Option) (*ZipFS, error) {
 	file, err := os.Open(name)
 	if err != nil {
 		return nil, err
 	}
 	fi, err := file.Stat()
 	if err != nil {
+		file.Close()
 		return nil, err
 	}
-	zipReader, err := zip.NewReader(file, fi.Size())
+	return NewFromReaderAt(file, fi.Size(), file, opts...)
+}
+
+// NewFromFile instantiates and returns a Zip filesystem backed by an
+// already-open file handle, such as one obtained from os.Open or os.OpenFile.
+// The returned ZipFS takes ownership of f and closes it when Close is
+// called.
+func NewFromFile(f *os.File, opts ...Option) (*ZipFS, error) {
+	fi, err := f.Stat()
 	if err != nil {
+		f.Close()
 		return nil, err
 	}
+	return NewFromReaderAt(f, fi.Size(), f, opts...)
+}
+
+// NewFromReaderAt instantiates and returns a Zip filesystem backed by ra, a
+// region of size bytes interpreted as a zip archive. This allows mounting
+// zip filesystems that live in memory (e.g. a bytes.Reader over an
+// embed.FS asset), behind an io.ReaderAt adapter over HTTP range requests,
+// or anywhere else a plain file path isn't available. If closer is non-nil
+// it is called when the returned ZipFS is closed; pass nil if ra does not
+// need closing.
+func NewFromReaderAt(ra io.ReaderAt, size int64, closer io.Closer, opts ...Option) (*ZipFS, error) {
+	zipReader, err := zip.NewReader(ra, size)
+	if err != nil {
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, err
+	}
+
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
 
 	// Separate the file into an io.ReaderAt and an io.Closer.
 	// Earlier versions of the code allowed for opening a filesystem
@@ -66,26 +102,66 @@ func New(name string) (*ZipFS, error) {
 	// does not need to be in the ZipFS structure. Keeping it there for now
 	// but may remove it in future.
 	fs := &ZipFS{
-		closer:    file,
-		readerAt:  file,
+		closer:    closer,
+		readerAt:  ra,
 		reader:    zipReader,
 		fileInfos: fileInfoMap{},
+		opts:      o,
 	}
 	for _, zf := range fs.reader.File {
 		fi := fs.fileInfos.FindOrCreate(zf.Name)
 		fi.zipFile = zf
 		fiParent := fs.fileInfos.FindOrCreateParent(zf.Name)
-		fiParent.fileInfos = append(fiParent.fileInfos, fi)
+		fs.fileInfos.link(fiParent, fi)
 	}
-	// Sort fileInfos in each directory.
+	// Sort fileInfos in each directory, and link every fileInfo back to fs
+	// so fileReader can reach fs.cache.
 	for _, fi := range fs.fileInfos {
+		fi.fs = fs
 		if len(fi.fileInfos) > 1 {
 			sort.Sort(fi.fileInfos)
 		}
 	}
+
+	if o.caseInsensitive || o.normalization != NormNone {
+		fs.buildFoldedIndex()
+	}
 	return fs, nil
 }
 
+// buildFoldedIndex populates fs.foldedIndex, a secondary lookup keyed by
+// each entry's folded name (see foldName), for use by openFileInfo once an
+// exact match misses. Directory entries are indexed once under their
+// slash-less key, matching the keys fileInfoMap itself exposes unrooted.
+//
+// When two entries fold to the same key (e.g. "Foo.txt" and "foo.txt" under
+// CaseInsensitive), the first one visited wins; keys are visited in sorted
+// order, not Go's randomized map iteration order, so that choice is
+// deterministic rather than varying from call to call.
+func (fs *ZipFS) buildFoldedIndex() {
+	keys := make([]string, 0, len(fs.fileInfos))
+	for key := range fs.fileInfos {
+		if key == "" || strings.HasSuffix(key, "/") {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fs.foldedIndex = map[string]*fileInfo{}
+	for _, key := range keys {
+		fi := fs.fileInfos[key]
+		name := key
+		if fi.zipFile != nil {
+			name = strings.TrimRight(decodeEntryName(fi.zipFile), "/")
+		}
+		folded := foldName(name, fs.opts)
+		if _, exists := fs.foldedIndex[folded]; !exists {
+			fs.foldedIndex[folded] = fi
+		}
+	}
+}
+
 // Open a path within the Zip filesystem for read
 func (fs *ZipFS) Open(name string) (http.File, error) {
 	fi, err := fs.openFileInfo(name)
@@ -104,10 +180,24 @@ func (fs *ZipFS) Close() error {
 		err = fs.closer.Close()
 		fs.closer = nil
 	}
+	if fs.cache != nil {
+		fs.cache.close()
+	}
 	fs.fileInfos = nil
 	return err
 }
 
+// WithCache installs a bounded in-memory decompression cache on fs,
+// governed by opts, and returns fs for chaining. Without WithCache, every
+// seek within an entry still shares a single decompressed temp file across
+// fileReaders (see fileInfo.openSpill); WithCache adds an LRU in front of
+// that so entries small enough to fit under opts.MaxEntryBytes are served
+// straight out of memory instead of ever touching disk.
+func (fs *ZipFS) WithCache(opts CacheOptions) *ZipFS {
+	fs.cache = newMemCache(opts)
+	return fs
+}
+
 type fileInfoList []*fileInfo
 
 func (fl fileInfoList) Len() int {
@@ -133,6 +223,9 @@ func (fs *ZipFS) openFileInfo(name string) (*fileInfo, error) {
 	name = path.Clean(name)
 	trimmedName := strings.TrimLeft(name, "/")
 	fi := fs.fileInfos[trimmedName]
+	if fi == nil && fs.foldedIndex != nil {
+		fi = fs.foldedIndex[foldName(trimmedName, fs.opts)]
+	}
 	if fi == nil {
 		return nil, &os.PathError{Op: "Open", Path: name, Err: os.ErrNotExist}
 	}
@@ -158,6 +251,13 @@ func (fm fileInfoMap) FindOrCreate(name string) *fileInfo {
 	return fi
 }
 
+// FindOrCreateParent returns the directory fileInfo for the parent of name,
+// creating and linking every intermediate ancestor directory that doesn't
+// already have an entry of its own. Most zip writers only emit directory
+// records when a producer explicitly adds one, so the parent of e.g.
+// "dir/sub/c.txt" is normally synthesized several levels at once; each
+// newly synthesized ancestor is linked into its own parent via link so it
+// still shows up in ReadDir and fs.WalkDir.
 func (fm fileInfoMap) FindOrCreateParent(name string) *fileInfo {
 	strippedName := strings.TrimRight(name, "/")
 	dirName := path.Dir(strippedName)
@@ -166,7 +266,24 @@ func (fm fileInfoMap) FindOrCreateParent(name string) *fileInfo {
 	} else if !strings.HasSuffix(dirName, "/") {
 		dirName = dirName + "/"
 	}
-	return fm.FindOrCreate(dirName)
+
+	existed := fm[dirName] != nil
+	fi := fm.FindOrCreate(dirName)
+	if !existed && dirName != "/" {
+		fm.link(fm.FindOrCreateParent(dirName), fi)
+	}
+	return fi
+}
+
+// link adds child to parent.fileInfos, unless it has already been linked
+// into some parent (its own explicit zip entry may be processed both before
+// and after it was synthesized as an ancestor of another entry).
+func (fm fileInfoMap) link(parent, child *fileInfo) {
+	if child.linked {
+		return
+	}
+	parent.fileInfos = append(parent.fileInfos, child)
+	child.linked = true
 }
 
 // fileInfo implements the os.FileInfo interface.
@@ -175,7 +292,9 @@ type fileInfo struct {
 	fs        *ZipFS
 	zipFile   *zip.File
 	fileInfos fileInfoList
+	linked    bool
 	tempPath  string
+	refCount  int
 	mutex     sync.Mutex
 }
 
@@ -239,13 +358,54 @@ func (fi *fileInfo) readdir() ([]os.FileInfo, error) {
 	return v, nil
 }
 
+// openSpill returns an independently-seekable *os.File over fi's
+// decompressed contents, decompressing fi.zipFile into a temp file and
+// sharing that temp file across every caller via refCount. Once closeSpill
+// has released the last reference the temp file is removed, and the next
+// openSpill decompresses the entry again. Each call to openSpill must be
+// matched by a call to closeSpill once the returned file is closed.
+func (fi *fileInfo) openSpill() (*os.File, error) {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	if fi.tempPath == "" {
+		tempFile, err := createTempFile(fi.zipFile)
+		if err != nil {
+			return nil, err
+		}
+		fi.tempPath = tempFile.Name()
+		tempFile.Close()
+	}
+	f, err := os.Open(fi.tempPath)
+	if err != nil {
+		return nil, err
+	}
+	fi.refCount++
+	return f, nil
+}
+
+// closeSpill releases a reference acquired by openSpill, removing the
+// shared temp file once the last reference is gone.
+func (fi *fileInfo) closeSpill() {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	fi.refCount--
+	if fi.refCount <= 0 && fi.tempPath != "" {
+		os.Remove(fi.tempPath)
+		fi.tempPath = ""
+	}
+}
+
 type fileReader struct {
 	name     string // the name used to open
 	fileInfo *fileInfo
 	reader   io.ReadCloser
-	file     *os.File
+	pos      int64             // bytes consumed through reader so far; stale once seek is set
+	seek     io.ReadSeekCloser // set by createSeeker on the first non-trivial Seek
+	spilled  bool              // true if seek came from fileInfo.openSpill
 	closed   bool
-	readdir  []os.FileInfo
+
+	readdir     []os.FileInfo
+	readdirRead bool // true once the directory listing has been fetched, even if now exhausted
 }
 
 func (f *fileReader) Close() error {
@@ -254,15 +414,12 @@ func (f *fileReader) Close() error {
 		err := f.reader.Close()
 		errs = append(errs, err)
 	}
-	var tempFile string
-	if f.file != nil {
-		tempFile = f.file.Name()
-		err := f.file.Close()
-		errs = append(errs, err)
-	}
-	if tempFile != "" {
-		err := os.Remove(tempFile)
+	if f.seek != nil {
+		err := f.seek.Close()
 		errs = append(errs, err)
+		if f.spilled {
+			f.fileInfo.closeSpill()
+		}
 	}
 
 	f.closed = true
@@ -279,8 +436,8 @@ func (f *fileReader) Read(p []byte) (n int, err error) {
 	if f.closed {
 		return 0, f.pathError("Read", errFileClosed)
 	}
-	if f.file != nil {
-		return f.file.Read(p)
+	if f.seek != nil {
+		return f.seek.Read(p)
 	}
 	if f.reader == nil {
 		f.reader, err = f.fileInfo.zipFile.Open()
@@ -288,75 +445,113 @@ func (f *fileReader) Read(p []byte) (n int, err error) {
 			return 0, err
 		}
 	}
-	return f.reader.Read(p)
+	n, err = f.reader.Read(p)
+	f.pos += int64(n)
+	return n, err
 }
 
 func (f *fileReader) Seek(offset int64, whence int) (int64, error) {
 	if f.closed {
 		return 0, f.pathError("Seek", errFileClosed)
 	}
+	if f.seek != nil {
+		return f.seek.Seek(offset, whence)
+	}
 	if f.reader != nil {
 		if err := f.reader.Close(); err != nil {
 			return 0, err
 		}
+		f.reader = nil
 	}
-	if f.file == nil && offset == 0 && whence == 0 {
+	if offset == 0 && whence == io.SeekStart {
+		f.pos = 0
 		var err error
 		f.reader, err = f.fileInfo.zipFile.Open()
 		return 0, err
 	}
-	if err := f.createTempFile(); err != nil {
+	if err := f.createSeeker(); err != nil {
 		return 0, err
 	}
-	return f.file.Seek(offset, whence)
+	return f.seek.Seek(offset, whence)
 }
 
+// Readdir implements http.File. With count > 0 it returns at most count
+// entries per call; a call that returns the last remaining entries also
+// returns io.EOF along with them (unlike os.File, which returns nil there),
+// and every later call returns no entries and io.EOF. With count <= 0 it
+// returns every remaining entry in one call,
+// which is empty once a prior call (of either form) has drained the
+// listing. The listing itself is fetched at most once per fileReader.
 func (f *fileReader) Readdir(count int) ([]os.FileInfo, error) {
-	var err error
-	var osFileInfos []os.FileInfo
-	if count > 0 {
-		if f.readdir == nil {
-			f.readdir, err = f.fileInfo.readdir()
-			if err != nil {
-				return nil, f.pathError("Readdir", err)
-			}
-		}
-		if len(f.readdir) >= count {
-			osFileInfos = f.readdir[0:count]
-			f.readdir = f.readdir[count:]
-		} else {
-			osFileInfos = f.readdir
-			f.readdir = nil
-			err = io.EOF
-		}
-	} else {
-		osFileInfos, err = f.fileInfo.readdir()
+	if !f.readdirRead {
+		all, err := f.fileInfo.readdir()
 		if err != nil {
 			return nil, f.pathError("Readdir", err)
 		}
+		f.readdir = all
+		f.readdirRead = true
+	}
+
+	if count <= 0 {
+		osFileInfos := f.readdir
+		f.readdir = nil
+		return osFileInfos, nil
+	}
+
+	if len(f.readdir) == 0 {
+		return nil, io.EOF
+	}
+	if len(f.readdir) >= count {
+		osFileInfos := f.readdir[0:count]
+		f.readdir = f.readdir[count:]
+		return osFileInfos, nil
 	}
-	return osFileInfos, err
+	osFileInfos := f.readdir
+	f.readdir = nil
+	return osFileInfos, io.EOF
 }
 
 func (f *fileReader) Stat() (os.FileInfo, error) {
 	return f.fileInfo, nil
 }
 
-func (f *fileReader) createTempFile() error {
-	if f.reader != nil {
-		if err := f.reader.Close(); err != nil {
+// createSeeker populates f.seek, preferring fs.cache's in-memory LRU (if
+// configured and f.fileInfo is small enough to qualify) and otherwise
+// falling back to fileInfo.openSpill's shared temp file. The new seeker
+// starts at offset 0 regardless of how much of f.reader's sequential stream
+// had already been consumed, so createSeeker aligns it to f.pos before
+// handing it back, keeping the file's logical position continuous across
+// the switch from sequential to seekable reads.
+func (f *fileReader) createSeeker() error {
+	if f.seek != nil {
+		return nil
+	}
+	var seeker io.ReadSeekCloser
+	spilled := false
+	if fs := f.fileInfo.fs; fs != nil && fs.cache != nil {
+		s, ok, err := fs.cache.open(f.fileInfo)
+		if err != nil {
 			return err
 		}
-		f.reader = nil
+		if ok {
+			seeker = s
+		}
 	}
-	if f.file == nil {
-		// Open a file that contains the contents of the zip file.
-		osFile, err := createTempFile(f.fileInfo.zipFile)
+	if seeker == nil {
+		osFile, err := f.fileInfo.openSpill()
 		if err != nil {
 			return err
 		}
-		f.file = osFile
+		seeker = osFile
+		spilled = true
+	}
+	if f.pos != 0 {
+		if _, err := seeker.Seek(f.pos, io.SeekStart); err != nil {
+			return err
+		}
 	}
+	f.seek = seeker
+	f.spilled = spilled
 	return nil
 }
 
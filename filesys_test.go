@@ -0,0 +1,48 @@
+// Copyright 2013-2018 C Hansen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipfs
+
+import (
+	"bytes"
+	"testing"
+)
+
+// trackingCloser records whether Close was called, standing in for the
+// *os.File that New and NewFromFile hand to NewFromReaderAt as closer.
+type trackingCloser struct {
+	closed bool
+}
+
+func (c *trackingCloser) Close() error {
+	c.closed = true
+	return nil
+}
+
+// TestNewFromReaderAtClosesOnInvalidZip verifies that NewFromReaderAt closes
+// the caller-supplied closer when the data isn't a valid zip archive, so
+// callers like New and NewFromFile don't leak the underlying file on that
+// error path.
+func TestNewFromReaderAtClosesOnInvalidZip(t *testing.T) {
+	notAZip := bytes.NewReader([]byte("not a zip file"))
+	closer := &trackingCloser{}
+
+	_, err := NewFromReaderAt(notAZip, int64(notAZip.Len()), closer)
+	if err == nil {
+		t.Fatal("NewFromReaderAt: expected error for invalid zip data, got nil")
+	}
+	if !closer.closed {
+		t.Error("NewFromReaderAt: closer was not closed on zip.NewReader error")
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2013-2018 C Hansen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipfs
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileServer returns an http.Handler that serves files out of fs.
+//
+// Unlike http.FileServer(fs), it avoids decompressing entries whenever
+// possible: Store-method entries are served straight out of the archive via
+// http.ServeContent, which gets Range support for free. Deflate-method
+// entries are served to clients that accept a gzip Content-Encoding by
+// wrapping the entry's raw deflate bytes in a synthesized gzip header and
+// trailer rather than decompressing and re-compressing, and zstd- or
+// bzip2-method entries (see the zipfs/codecs package) are served the same
+// way to clients that accept the matching Content-Encoding, since a zip
+// entry's compressed bytes are already a complete stream in that format.
+// Every response for a file entry also gets a strong ETag derived from the
+// entry's CRC32 and size, and a Last-Modified header from the zip.File's
+// ModTime; directories and missing names are handed to http.FileServer
+// without that ETag.
+//
+// These compressed-passthrough fast paths only apply when the request has
+// no Range header: a byte range over the compressed bytes doesn't correspond
+// to the equivalent range of the decompressed content, so Range requests
+// against a Deflate/zstd/bzip2 entry always fall back to full decompression
+// through ZipFS.Open, same as requests whose Accept-Encoding doesn't permit
+// the entry's format.
+func FileServer(fs *ZipFS) http.Handler {
+	return &fileServer{fs: fs}
+}
+
+// passthroughEncodings maps the zip compression methods whose compressed
+// bytes are themselves a complete, independently-valid stream in a
+// Content-Encoding HTTP clients understand, so they can be served to an
+// accepting client without any reframing (contrast Deflate, which needs a
+// synthesized gzip header and trailer; see writeSyntheticGzip).
+var passthroughEncodings = map[uint16]string{
+	ZSTD:  "zstd",
+	BZIP2: "bzip2",
+}
+
+// Handler is equivalent to FileServer(z).
+func (z *ZipFS) Handler() http.Handler {
+	return FileServer(z)
+}
+
+type fileServer struct {
+	fs *ZipFS
+}
+
+func (h *fileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := path.Clean(r.URL.Path)
+	fi, err := h.fs.openFileInfo(name)
+	if err != nil || fi.IsDir() {
+		// Directory listing, index.html redirection, and path cleaning are
+		// already implemented by http.FileServer against fs's
+		// http.FileSystem; there's nothing compression-specific to add there.
+		http.FileServer(h.fs).ServeHTTP(w, r)
+		return
+	}
+
+	zf := fi.zipFile
+	etag := entryETag(zf)
+	w.Header().Set("ETag", etag)
+
+	offset, offsetErr := zf.DataOffset()
+
+	if zf.Method == zip.Deflate && offsetErr == nil && r.Header.Get("Range") == "" && acceptsEncoding(r, "gzip") {
+		if checkNotModified(r, etag, fi.ModTime()) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", contentTypeFor(name))
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Last-Modified", fi.ModTime().UTC().Format(http.TimeFormat))
+		// The synthesized gzip stream is gzipHeader + the entry's raw
+		// deflate bytes + an 8-byte trailer; the length is known up front,
+		// so HEAD can report it without writing a body.
+		w.Header().Set("Content-Length", strconv.FormatInt(int64(len(gzipHeader))+int64(zf.CompressedSize64)+8, 10))
+		if r.Method == http.MethodHead {
+			return
+		}
+		sr := io.NewSectionReader(h.fs.readerAt, offset, int64(zf.CompressedSize64))
+		if err := writeSyntheticGzip(w, sr, zf.CRC32, zf.UncompressedSize64); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if zf.Method == zip.Store && offsetErr == nil {
+		sr := io.NewSectionReader(h.fs.readerAt, offset, int64(zf.CompressedSize64))
+		http.ServeContent(w, r, name, fi.ModTime(), sr)
+		return
+	}
+
+	if encoding, ok := passthroughEncodings[zf.Method]; ok && offsetErr == nil && r.Header.Get("Range") == "" && acceptsEncoding(r, encoding) {
+		if checkNotModified(r, etag, fi.ModTime()) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", contentTypeFor(name))
+		w.Header().Set("Content-Encoding", encoding)
+		w.Header().Set("Last-Modified", fi.ModTime().UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.FormatInt(int64(zf.CompressedSize64), 10))
+		if r.Method == http.MethodHead {
+			return
+		}
+		sr := io.NewSectionReader(h.fs.readerAt, offset, int64(zf.CompressedSize64))
+		buf := bufPool.Get()
+		defer bufPool.Free(buf)
+		if _, err := io.CopyBuffer(w, sr, buf[:]); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	rd, err := h.fs.Open(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rd.Close()
+	http.ServeContent(w, r, name, fi.ModTime(), rd)
+}
+
+// entryETag derives a strong ETag from zf's CRC32 and uncompressed size,
+// both of which are already recorded in the zip's central directory and
+// never require reading the entry's contents.
+func entryETag(zf *zip.File) string {
+	return fmt.Sprintf("\"%08x-%x\"", zf.CRC32, zf.UncompressedSize64)
+}
+
+// acceptsEncoding reports whether r's Accept-Encoding header permits
+// encoding, honoring explicit q=0 refusals (RFC 7231 §5.3.4) rather than
+// treating any mention of encoding as acceptance.
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		fields := strings.Split(part, ";")
+		name := strings.TrimSpace(fields[0])
+		if !strings.EqualFold(name, encoding) {
+			continue
+		}
+		for _, param := range fields[1:] {
+			key, value, _ := strings.Cut(param, "=")
+			if strings.TrimSpace(key) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// checkNotModified reports whether r's conditional headers indicate the
+// client's cached copy, identified by etag, is still current. It mirrors
+// the subset of net/http's unexported conditional-request handling that
+// ServeHTTP needs for the paths that bypass http.ServeContent.
+func checkNotModified(r *http.Request, etag string, modTime time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if inm == "*" {
+			return true
+		}
+		for _, tag := range strings.Split(inm, ",") {
+			if strings.TrimSpace(tag) == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil && !modTime.Truncate(time.Second).After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func contentTypeFor(name string) string {
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		return ctype
+	}
+	return "application/octet-stream"
+}
+
+// gzipHeader is a minimal 10-byte gzip member header: magic, deflate
+// compression method, no flags, zero mtime, and an unknown OS byte.
+var gzipHeader = [10]byte{0x1f, 0x8b, 8, 0, 0, 0, 0, 0, 0, 0xff}
+
+// writeSyntheticGzip writes a valid gzip stream to w by wrapping raw, a
+// zip entry's already-Deflate-compressed bytes, in a gzip header and
+// trailer. The stream decompresses to the entry's content without ever
+// running the deflate codec; its bytes are the archive's own deflate data,
+// not necessarily what a gzip encoder would produce from the content.
+func writeSyntheticGzip(w io.Writer, raw io.Reader, crc32 uint32, uncompressedSize uint64) error {
+	if _, err := w.Write(gzipHeader[:]); err != nil {
+		return err
+	}
+	buf := bufPool.Get()
+	defer bufPool.Free(buf)
+	if _, err := io.CopyBuffer(w, raw, buf[:]); err != nil {
+		return err
+	}
+	var trailer [8]byte
+	binary.LittleEndian.PutUint32(trailer[0:4], crc32)
+	binary.LittleEndian.PutUint32(trailer[4:8], uint32(uncompressedSize))
+	_, err := w.Write(trailer[:])
+	return err
+}
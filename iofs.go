@@ -0,0 +1,223 @@
+// Copyright 2013-2018 C Hansen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipfs
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// FS returns an io/fs.FS view of the filesystem. The returned value also
+// implements fs.ReadDirFS, fs.StatFS, fs.ReadFileFS, fs.GlobFS and fs.SubFS,
+// so it can be used anywhere the standard library expects an io/fs.FS, e.g.
+// with fs.WalkDir or testing/fstest.TestFS.
+//
+// FS is an adapter over the same fileInfoMap that backs ZipFS's
+// http.FileSystem implementation, so names resolved through FS and through
+// ZipFS.Open see an identical tree; it exists so that ZipFS.Open can keep
+// returning http.File for existing callers.
+func (z *ZipFS) FS() fs.FS {
+	return &ioFS{zfs: z}
+}
+
+// ReadDir reads the named directory and returns a list of directory
+// entries sorted by filename, as required by fs.ReadDirFS.
+func (z *ZipFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return (&ioFS{zfs: z}).ReadDir(name)
+}
+
+// Stat returns an fs.FileInfo describing the named file, as required by
+// fs.StatFS.
+func (z *ZipFS) Stat(name string) (fs.FileInfo, error) {
+	return (&ioFS{zfs: z}).Stat(name)
+}
+
+// ReadFile reads the named file and returns its contents, as required by
+// fs.ReadFileFS.
+func (z *ZipFS) ReadFile(name string) ([]byte, error) {
+	return (&ioFS{zfs: z}).ReadFile(name)
+}
+
+// Glob returns the unrooted names of every file matching pattern, using the
+// syntax of path.Match, as required by fs.GlobFS.
+func (z *ZipFS) Glob(pattern string) ([]string, error) {
+	return (&ioFS{zfs: z}).Glob(pattern)
+}
+
+// Sub returns an fs.FS corresponding to the subtree rooted at dir, backed by
+// the same underlying readerAt as z, as required by fs.SubFS.
+func (z *ZipFS) Sub(dir string) (fs.FS, error) {
+	return (&ioFS{zfs: z}).Sub(dir)
+}
+
+// ioFS adapts a ZipFS's fileInfoMap to the io/fs interfaces. root is an
+// unrooted, slash-separated path ("" for the filesystem root) that every
+// name passed to the methods below is resolved relative to.
+type ioFS struct {
+	zfs  *ZipFS
+	root string
+}
+
+// join resolves name, which must already satisfy fs.ValidPath, against root.
+func (w *ioFS) join(name string) string {
+	if name == "." {
+		return w.root
+	}
+	if w.root == "" {
+		return name
+	}
+	return w.root + "/" + name
+}
+
+func (w *ioFS) lookup(op, name string) (*fileInfo, error) {
+	if w.zfs.fileInfos == nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: errFileSystemClosed}
+	}
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	key := w.join(name)
+	if key == "" {
+		key = "/"
+	}
+	fi := w.zfs.fileInfos[key]
+	if fi == nil && w.zfs.foldedIndex != nil {
+		fi = w.zfs.foldedIndex[foldName(key, w.zfs.opts)]
+	}
+	if fi == nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return fi, nil
+}
+
+// Open implements fs.FS.
+func (w *ioFS) Open(name string) (fs.File, error) {
+	fi, err := w.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return &fsFile{fileReader: fi.openReader(name)}, nil
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (w *ioFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	fi, err := w.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := fi.readdir()
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	return entries, nil
+}
+
+// Stat implements fs.StatFS.
+func (w *ioFS) Stat(name string) (fs.FileInfo, error) {
+	return w.lookup("stat", name)
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (w *ioFS) ReadFile(name string) ([]byte, error) {
+	fi, err := w.lookup("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: errDirectory}
+	}
+	r, err := fi.zipFile.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// Glob implements fs.GlobFS by matching pattern against every entry in the
+// underlying fileInfoMap.
+func (w *ioFS) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	var names []string
+	for key := range w.zfs.fileInfos {
+		// fileInfoMap stores directories under two keys (with and without a
+		// trailing slash); skip the slashed one so each entry is matched once.
+		if key == "" || strings.HasSuffix(key, "/") {
+			continue
+		}
+		name := w.relName(key)
+		if name == "" {
+			continue
+		}
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Sub implements fs.SubFS.
+func (w *ioFS) Sub(dir string) (fs.FS, error) {
+	fi, err := w.lookup("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: errNotDirectory}
+	}
+	return &ioFS{zfs: w.zfs, root: w.join(dir)}, nil
+}
+
+// relName returns key's path relative to root, or "" if key is root itself
+// or outside of it.
+func (w *ioFS) relName(key string) string {
+	if w.root == "" {
+		return key
+	}
+	if key == w.root {
+		return ""
+	}
+	prefix := w.root + "/"
+	if !strings.HasPrefix(key, prefix) {
+		return ""
+	}
+	return key[len(prefix):]
+}
+
+// fsFile adapts fileReader to fs.File and, for directories, fs.ReadDirFile.
+type fsFile struct {
+	*fileReader
+}
+
+// ReadDir implements fs.ReadDirFile, honoring the same count semantics as
+// fileReader.Readdir.
+func (f *fsFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	infos, err := f.fileReader.Readdir(n)
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	return entries, err
+}
@@ -0,0 +1,59 @@
+// Copyright 2013-2018 C Hansen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+	"testing/fstest"
+)
+
+// buildFixtureZip returns the bytes of a zip archive containing a.txt at
+// the root and two files nested under dir/, neither of which has an
+// explicit directory record of its own (the common case: archive/zip.Writer
+// never writes one unless the producer calls CreateHeader with a trailing
+// slash).
+func buildFixtureZip(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"a.txt", "dir/b.txt", "dir/sub/c.txt"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Create(%q): %v", name, err)
+		}
+		if _, err := w.Write([]byte("contents of " + name + "\n")); err != nil {
+			t.Fatalf("Write(%q): %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestFSConformance(t *testing.T) {
+	data := buildFixtureZip(t)
+	z, err := NewFromReaderAt(bytes.NewReader(data), int64(len(data)), nil)
+	if err != nil {
+		t.Fatalf("NewFromReaderAt: %v", err)
+	}
+	defer z.Close()
+
+	if err := fstest.TestFS(z.FS(), "a.txt", "dir/b.txt", "dir/sub/c.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2013-2018 C Hansen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipfs
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	normNFC = norm.NFC
+	normNFD = norm.NFD
+)
+
+// UnicodeNormalization selects how entry names are folded for lookup when
+// Normalization is passed to New, NewFromReaderAt, NewFromFile, or
+// NewFromExecutable.
+type UnicodeNormalization int
+
+const (
+	// NormNone performs no Unicode normalization.
+	NormNone UnicodeNormalization = iota
+	// NormNFC folds entry names to Unicode Normalization Form C, the form
+	// produced by most tools outside of macOS.
+	NormNFC
+	// NormNFD folds entry names to Unicode Normalization Form D, the form
+	// the macOS Finder and HFS+/APFS use when writing file names.
+	NormNFD
+)
+
+// Option configures a ZipFS constructed by New, NewFromReaderAt,
+// NewFromFile, or NewFromExecutable.
+type Option func(*options)
+
+type options struct {
+	caseInsensitive bool
+	normalization   UnicodeNormalization
+}
+
+// CaseInsensitive makes name lookups fold case, so zips authored on
+// case-insensitive filesystems (Windows, default macOS) are still browsable
+// by exact-case callers on a case-sensitive one.
+func CaseInsensitive() Option {
+	return func(o *options) { o.caseInsensitive = true }
+}
+
+// Normalization makes name lookups fold to form before matching, so zips
+// with NFD-decomposed names (as produced by macOS) are found by their
+// NFC-composed equivalents, or vice versa.
+func Normalization(form UnicodeNormalization) Option {
+	return func(o *options) { o.normalization = form }
+}
+
+// foldName returns name folded according to o, for use as a lookup-index
+// key. It is a no-op unless CaseInsensitive or Normalization was requested.
+func foldName(name string, o options) string {
+	switch o.normalization {
+	case NormNFC:
+		name = normNFC.String(name)
+	case NormNFD:
+		name = normNFD.String(name)
+	}
+	if o.caseInsensitive {
+		name = strings.ToLower(name)
+	}
+	return name
+}
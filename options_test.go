@@ -0,0 +1,140 @@
+// Copyright 2013-2018 C Hansen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+)
+
+func buildOptionsFixtureZip(t *testing.T, entries map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Create(%q): %v", name, err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("Write(%q): %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestCaseInsensitiveLookup verifies that CaseInsensitive lets a differently
+// cased name resolve to the entry, and that without it the same lookup
+// fails.
+func TestCaseInsensitiveLookup(t *testing.T) {
+	data := buildOptionsFixtureZip(t, map[string]string{"Foo.txt": "contents"})
+
+	z, err := NewFromReaderAt(bytes.NewReader(data), int64(len(data)), nil, CaseInsensitive())
+	if err != nil {
+		t.Fatalf("NewFromReaderAt: %v", err)
+	}
+	defer z.Close()
+
+	got, err := z.ReadFile("foo.txt")
+	if err != nil {
+		t.Fatalf("ReadFile(%q) with CaseInsensitive: %v", "foo.txt", err)
+	}
+	if string(got) != "contents" {
+		t.Errorf("ReadFile(%q) = %q, want %q", "foo.txt", got, "contents")
+	}
+
+	plain, err := NewFromReaderAt(bytes.NewReader(data), int64(len(data)), nil)
+	if err != nil {
+		t.Fatalf("NewFromReaderAt: %v", err)
+	}
+	defer plain.Close()
+	if _, err := plain.ReadFile("foo.txt"); err == nil {
+		t.Error("ReadFile(\"foo.txt\") without CaseInsensitive unexpectedly succeeded")
+	}
+}
+
+// TestCaseInsensitiveFoldCollisionIsDeterministic verifies that when two
+// entries fold to the same key, the one chosen is stable across repeated
+// construction rather than varying with Go's randomized map iteration
+// order (see buildFoldedIndex).
+func TestCaseInsensitiveFoldCollisionIsDeterministic(t *testing.T) {
+	data := buildOptionsFixtureZip(t, map[string]string{
+		"Foo.txt": "upper",
+		"foo.txt": "lower",
+	})
+
+	var first string
+	for i := 0; i < 10; i++ {
+		z, err := NewFromReaderAt(bytes.NewReader(data), int64(len(data)), nil, CaseInsensitive())
+		if err != nil {
+			t.Fatalf("NewFromReaderAt: %v", err)
+		}
+		got, err := z.ReadFile("FOO.TXT")
+		z.Close()
+		if err != nil {
+			t.Fatalf("ReadFile(\"FOO.TXT\"): %v", err)
+		}
+		if i == 0 {
+			first = string(got)
+			continue
+		}
+		if string(got) != first {
+			t.Fatalf("fold collision resolved to %q on run %d, want %q (same as run 0)", got, i, first)
+		}
+	}
+}
+
+// TestNormalizationLookup verifies that Normalization lets an entry whose
+// name is stored in one Unicode normalization form be found via a lookup
+// name in the other form, as happens when a zip authored on macOS (NFD
+// names) is browsed with an NFC-composed path.
+func TestNormalizationLookup(t *testing.T) {
+	// nfc spells the accented e as the single composed code point U+00E9;
+	// nfd spells it as 'e' (U+0065) followed by the combining acute accent
+	// U+0301. Both render as "café.txt" but are different byte sequences.
+	const (
+		nfc = "café.txt"
+		nfd = "café.txt"
+	)
+
+	data := buildOptionsFixtureZip(t, map[string]string{nfd: "contents"})
+
+	z, err := NewFromReaderAt(bytes.NewReader(data), int64(len(data)), nil, Normalization(NormNFC))
+	if err != nil {
+		t.Fatalf("NewFromReaderAt: %v", err)
+	}
+	defer z.Close()
+
+	got, err := z.ReadFile(nfc)
+	if err != nil {
+		t.Fatalf("ReadFile(%q) with Normalization(NormNFC): %v", nfc, err)
+	}
+	if string(got) != "contents" {
+		t.Errorf("ReadFile(%q) = %q, want %q", nfc, got, "contents")
+	}
+
+	plain, err := NewFromReaderAt(bytes.NewReader(data), int64(len(data)), nil)
+	if err != nil {
+		t.Fatalf("NewFromReaderAt: %v", err)
+	}
+	defer plain.Close()
+	if _, err := plain.ReadFile(nfc); err == nil {
+		t.Errorf("ReadFile(%q) without Normalization unexpectedly succeeded", nfc)
+	}
+}